@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+// Backend selects which promoterImplementation is used to talk to
+// registries.
+type Backend string
+
+const (
+	// BackendLegacy shells out to gcloud/docker via streamProducerFunc,
+	// as the promoter has always done.
+	BackendLegacy Backend = "legacy"
+	// BackendGGCR talks to registries directly through
+	// go-containerregistry, giving native cross-repo blob mounting,
+	// real parallelism and retry/backoff.
+	BackendGGCR Backend = "ggcr"
+)
+
+// ggcrPromoterImplementation reimplements the registry-facing parts of
+// promoterImplementation on top of go-containerregistry instead of the
+// subprocess pipeline built by MakeProducerFunction. Everything else is
+// inherited from defaultPromoterImplementation so the two backends can
+// coexist during migration.
+type ggcrPromoterImplementation struct {
+	defaultPromoterImplementation
+	// opts is captured by implementationFor so methods whose interface
+	// signature has no *Options parameter (PromoteImages) can still
+	// read settings like Options.MaxConcurrentRequests.
+	opts *Options
+}
+
+func (gi *ggcrPromoterImplementation) MakeSyncContext(
+	opts *Options, mfests []reg.Manifest,
+) (*reg.SyncContext, error) {
+	sc, err := gi.defaultPromoterImplementation.MakeSyncContext(opts, mfests)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating base sync context")
+	}
+
+	// The ggcr backend resolves manifests and inventories directly
+	// against the registries instead of relying on gcloud credential
+	// helpers streamed through a subprocess, so no further setup is
+	// required here beyond what the default implementation provides.
+	return sc, nil
+}
+
+func (gi *ggcrPromoterImplementation) GetPromotionEdges(
+	sc *reg.SyncContext, mfests []reg.Manifest,
+) (map[reg.PromotionEdge]interface{}, error) {
+	return gi.defaultPromoterImplementation.GetPromotionEdges(sc, mfests)
+}
+
+// PromoteImages copies every Add/Move edge to its destination tag with
+// crane.Copy and removes every Delete edge's destination tag with
+// remote.Delete, both using ggcrJobs(Options) jobs for parallelism.
+func (gi *ggcrPromoterImplementation) PromoteImages(
+	sc *reg.SyncContext, edges map[reg.PromotionEdge]interface{}, _ streamProducerFunc,
+) error {
+	jobs := ggcrJobs(gi.opts)
+	keychain := remote.WithAuthFromKeychain(authn.DefaultKeychain)
+
+	for edge := range edges {
+		srcRef, err := name.ParseReference(edge.SrcImage.ImageName.String() + "@" + string(edge.Digest))
+		if err != nil {
+			return errors.Wrapf(err, "parsing source reference for %v", edge)
+		}
+
+		dstRef, err := name.ParseReference(edge.DstImageTag.ImageName.String() + ":" + string(edge.DstImageTag.Tag))
+		if err != nil {
+			return errors.Wrapf(err, "parsing destination reference for %v", edge)
+		}
+
+		switch edge.TagOp {
+		case reg.Delete:
+			if err := remote.Delete(dstRef, keychain, remote.WithJobs(jobs)); err != nil {
+				return errors.Wrapf(err, "deleting %s", dstRef)
+			}
+		case reg.Add, reg.Move:
+			if err := crane.Copy(
+				srcRef.String(), dstRef.String(),
+				crane.WithAuthFromKeychain(authn.DefaultKeychain), crane.WithJobs(jobs),
+			); err != nil {
+				return errors.Wrapf(err, "copying %s to %s", srcRef, dstRef)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetRegistryImageInventory lists the tags of each manifest's first
+// registry, taken as a single repository, with remote.List and resolves
+// each tag's digest with remote.Get instead of shelling out to gcloud.
+// Images in repositories nested below that one are not listed.
+func (gi *ggcrPromoterImplementation) GetRegistryImageInventory(
+	opts *Options, mfests []reg.Manifest,
+) (reg.RegInvImage, error) {
+	rii := make(reg.RegInvImage)
+	keychain := remote.WithAuthFromKeychain(authn.DefaultKeychain)
+
+	for _, mfest := range mfests {
+		repo, err := name.NewRepository(string(mfest.Registries[0].Name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing repository %s", mfest.Registries[0].Name)
+		}
+
+		tags, err := remote.List(repo, keychain)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listing tags for %s", repo)
+		}
+
+		for _, tag := range tags {
+			ref := repo.Tag(tag)
+
+			desc, err := remote.Get(ref, keychain)
+			if err != nil {
+				return nil, errors.Wrapf(err, "getting descriptor for %s", ref)
+			}
+
+			rii.RecordTag(reg.ImageName(repo.RepositoryStr()), reg.Digest(desc.Digest.String()), reg.Tag(tag))
+		}
+	}
+
+	return rii, nil
+}
+
+// DumpStagingInventory overrides defaultPromoterImplementation's version
+// because that one calls GetRegistryImageInventory on its own receiver:
+// since ggcrPromoterImplementation embeds defaultPromoterImplementation
+// by value rather than overriding every method that calls another
+// interface method on itself, the embedded call would always hit the
+// legacy listing path and never gi's ggcr-native one.
+func (gi *ggcrPromoterImplementation) DumpStagingInventory(opts *Options) (reg.RegInvImage, error) {
+	mfest := reg.Manifest{
+		Registries: []reg.RegistryContext{
+			{Name: reg.RegistryName(opts.StagingRepo), Src: true},
+		},
+	}
+
+	return gi.GetRegistryImageInventory(opts, []reg.Manifest{mfest})
+}
+
+// defaultGGCRJobs is used when Options.MaxConcurrentRequests is unset,
+// matching the legacy backend's default worker count so switching
+// Options.Backend does not change throughput by default.
+const defaultGGCRJobs = 10
+
+// ggcrJobs reads Options.MaxConcurrentRequests, falling back to
+// defaultGGCRJobs when it is unset.
+func ggcrJobs(opts *Options) int {
+	if opts == nil || opts.MaxConcurrentRequests <= 0 {
+		return defaultGGCRJobs
+	}
+
+	return opts.MaxConcurrentRequests
+}
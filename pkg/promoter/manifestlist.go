@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"github.com/pkg/errors"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+// ManifestListMode controls how PromoteImages handles a source reference
+// that resolves to a manifest list / OCI image index.
+type ManifestListMode string
+
+const (
+	// ManifestListModeStrict fails promotion when a manifest list is
+	// encountered. This is the original behavior.
+	ManifestListModeStrict ManifestListMode = "strict"
+	// ManifestListModeChildrenOnly copies every child manifest to the
+	// destination but does not push a destination-side index.
+	ManifestListModeChildrenOnly ManifestListMode = "children-only"
+	// ManifestListModeRebuild copies every child manifest and then
+	// reconstructs and pushes the index at the destination, rewriting
+	// platform entries with the destination digests. It is the default
+	// for new callers.
+	ManifestListModeRebuild ManifestListMode = "rebuild"
+)
+
+// ResolveManifestList inspects edge's source reference and reports
+// whether it is a manifest list / OCI image index. When it is, the
+// returned ImageWithDigestSlice enumerates its child manifests so the
+// caller can route the edge to PromoteManifestList instead of a plain
+// single-image copy.
+func (di *defaultPromoterImplementation) ResolveManifestList(
+	sc *reg.SyncContext, edge reg.PromotionEdge,
+) (reg.ImageWithDigestSlice, bool, error) {
+	index, isList, err := sc.GetManifestList(edge.SrcRegistry, edge.SrcImageTag, edge.Digest)
+	if err != nil {
+		return reg.ImageWithDigestSlice{}, false, errors.Wrap(err, "inspecting source manifest")
+	}
+
+	return index, isList, nil
+}
+
+// PromoteManifestList copies every child manifest referenced by a source
+// manifest list / OCI image index to the destination registry and,
+// depending on Options.ManifestListMode, reconstructs and pushes the
+// index at the destination. It mirrors the fallback podman's `push`
+// performs when asked to push a list: copy the children, then the list.
+func (di *defaultPromoterImplementation) PromoteManifestList(
+	opts *Options, sc *reg.SyncContext, edge reg.PromotionEdge,
+	index reg.ImageWithDigestSlice, producerFunc streamProducerFunc,
+) error {
+	if opts.ManifestListMode == ManifestListModeStrict {
+		return errors.Errorf(
+			"refusing to promote manifest list %s: ManifestListMode is strict",
+			edge.SrcImage.ImageName,
+		)
+	}
+
+	rebuiltChildren := make(reg.DigestSlice, 0, len(index.DigestSlice))
+
+	for _, child := range index.DigestSlice {
+		childEdge := edge
+		childEdge.Digest = child.Digest
+
+		dstDigest, err := sc.Promote(childEdge, producerFunc)
+		if err != nil {
+			return errors.Wrapf(err, "copying child manifest %s", child.Digest)
+		}
+
+		rebuiltChild := child
+		rebuiltChild.Digest = dstDigest
+		rebuiltChildren = append(rebuiltChildren, rebuiltChild)
+	}
+
+	if opts.ManifestListMode == ManifestListModeChildrenOnly {
+		return nil
+	}
+
+	rebuilt, err := rebuildManifestListForDestination(index, rebuiltChildren)
+	if err != nil {
+		return errors.Wrap(err, "rebuilding manifest list for destination")
+	}
+
+	return errors.Wrap(
+		sc.PushManifestList(edge.DstRegistry, edge.DstImageTag, rebuilt),
+		"pushing rebuilt manifest list",
+	)
+}
+
+// rebuildManifestListForDestination builds the index to push at the
+// destination from the digests each child manifest was actually given
+// once copied there (rebuiltChildren), not from the source index's own
+// digests — those describe the source registry and are never valid at
+// the destination.
+func rebuildManifestListForDestination(
+	index reg.ImageWithDigestSlice, rebuiltChildren reg.DigestSlice,
+) (reg.ImageWithDigestSlice, error) {
+	if len(rebuiltChildren) != len(index.DigestSlice) {
+		return reg.ImageWithDigestSlice{}, errors.Errorf(
+			"expected %d rebuilt child digests, got %d", len(index.DigestSlice), len(rebuiltChildren),
+		)
+	}
+
+	rebuilt := index
+	rebuilt.DigestSlice = rebuiltChildren
+
+	return rebuilt, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"testing"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+func TestRebuildManifestListForDestination(t *testing.T) {
+	index := reg.ImageWithDigestSlice{
+		DigestSlice: reg.DigestSlice{
+			{Digest: "sha256:aaaa"},
+			{Digest: "sha256:bbbb"},
+		},
+	}
+
+	rebuiltChildren := reg.DigestSlice{
+		{Digest: "sha256:dest-aaaa"},
+		{Digest: "sha256:dest-bbbb"},
+	}
+
+	rebuilt, err := rebuildManifestListForDestination(index, rebuiltChildren)
+	if err != nil {
+		t.Fatalf("rebuildManifestListForDestination() error = %v", err)
+	}
+
+	if len(rebuilt.DigestSlice) != len(rebuiltChildren) {
+		t.Fatalf("rebuilt has %d children, want %d", len(rebuilt.DigestSlice), len(rebuiltChildren))
+	}
+
+	for i, want := range rebuiltChildren {
+		if rebuilt.DigestSlice[i].Digest != want.Digest {
+			t.Errorf("rebuilt.DigestSlice[%d].Digest = %q, want %q", i, rebuilt.DigestSlice[i].Digest, want.Digest)
+		}
+
+		if rebuilt.DigestSlice[i].Digest == index.DigestSlice[i].Digest {
+			t.Errorf("rebuilt.DigestSlice[%d].Digest still matches the source digest %q, want the destination digest", i, index.DigestSlice[i].Digest)
+		}
+	}
+}
+
+func TestRebuildManifestListForDestinationChildCountMismatch(t *testing.T) {
+	index := reg.ImageWithDigestSlice{
+		DigestSlice: reg.DigestSlice{
+			{Digest: "sha256:aaaa"},
+			{Digest: "sha256:bbbb"},
+		},
+	}
+
+	if _, err := rebuildManifestListForDestination(index, reg.DigestSlice{{Digest: "sha256:dest-aaaa"}}); err == nil {
+		t.Fatal("rebuildManifestListForDestination() with mismatched child count: expected an error, got nil")
+	}
+}
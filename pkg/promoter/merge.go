@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+// WritePolicy controls where MergeStagingManifest writes the merged
+// thin manifest.
+type WritePolicy string
+
+const (
+	// WritePolicyInPlace overwrites the thin manifest file under
+	// Options.BaseDir.
+	WritePolicyInPlace WritePolicy = "in-place"
+	// WritePolicyStdout prints the merged manifest instead of writing
+	// it to disk.
+	WritePolicyStdout WritePolicy = "stdout"
+	// WritePolicyPullRequestBranch commits the merged manifest to a
+	// branch and leaves opening the pull request to the caller.
+	WritePolicyPullRequestBranch WritePolicy = "pull-request-branch"
+)
+
+// MergeDiff summarizes the image/tag/digest entries a merge would add
+// to the thin manifest it targets.
+type MergeDiff struct {
+	ManifestPath string
+	Added        []reg.Image
+}
+
+// MergeStagingManifest folds the staging registry's current inventory
+// into the thin manifest under Options.BaseDir, the in-process
+// replacement for the standalone cip-mm binary.
+func (p *Promoter) MergeStagingManifest(opts *Options) error {
+	impl := p.implementationFor(opts)
+
+	if err := impl.ValidateOptions(opts); err != nil {
+		return errors.Wrap(err, "validating options")
+	}
+
+	rii, err := impl.DumpStagingInventory(opts)
+	if err != nil {
+		return errors.Wrap(err, "dumping staging inventory")
+	}
+
+	rii, err = impl.FilterInventory(opts, rii)
+	if err != nil {
+		return errors.Wrap(err, "filtering staging inventory")
+	}
+
+	diff, err := impl.MergePromoterManifest(opts, rii)
+	if err != nil {
+		return errors.Wrap(err, "merging promoter manifest")
+	}
+
+	return errors.Wrap(printMergeDiff(diff), "printing merge diff")
+}
+
+// DumpStagingInventory lists every image/tag/digest currently present
+// in Options.StagingRepo.
+func (di *defaultPromoterImplementation) DumpStagingInventory(opts *Options) (reg.RegInvImage, error) {
+	mfest := reg.Manifest{
+		Registries: []reg.RegistryContext{
+			{Name: reg.RegistryName(opts.StagingRepo), Src: true},
+		},
+	}
+
+	return di.GetRegistryImageInventory(opts, []reg.Manifest{mfest})
+}
+
+// FilterInventory narrows a staging inventory down to the image, tag,
+// or digest named in Options.FilterImage, Options.FilterTag, and
+// Options.FilterDigest, when set.
+func (di *defaultPromoterImplementation) FilterInventory(
+	opts *Options, rii reg.RegInvImage,
+) (reg.RegInvImage, error) {
+	if opts.FilterImage == "" && opts.FilterTag == "" && opts.FilterDigest == "" {
+		return rii, nil
+	}
+
+	filtered := make(reg.RegInvImage)
+	for image, digestTags := range rii {
+		if opts.FilterImage != "" && string(image) != opts.FilterImage {
+			continue
+		}
+
+		for digest, tags := range digestTags {
+			if opts.FilterDigest != "" && string(digest) != opts.FilterDigest {
+				continue
+			}
+
+			keptTags := make(reg.TagSlice, 0, len(tags))
+			for _, tag := range tags {
+				if opts.FilterTag != "" && string(tag) != opts.FilterTag {
+					continue
+				}
+				keptTags = append(keptTags, tag)
+			}
+
+			if len(keptTags) > 0 {
+				filtered.SetTags(image, digest, keptTags)
+			}
+		}
+	}
+
+	return filtered, nil
+}
+
+// MergePromoterManifest locates the thin manifest under Options.BaseDir
+// that governs Options.StagingRepo (using the existing ThinManifestDepth
+// lookup), splices in the filtered inventory while preserving YAML
+// ordering and comments, and writes the result per Options.WritePolicy.
+// It refuses to add a digest that already exists in the manifest under
+// a different tag.
+func (di *defaultPromoterImplementation) MergePromoterManifest(
+	opts *Options, rii reg.RegInvImage,
+) (*MergeDiff, error) {
+	mfestPath, err := reg.ResolveThinManifestPath(opts.BaseDir, opts.StagingRepo)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving thin manifest path")
+	}
+
+	mfest, err := reg.ParseThinManifestFromFile(mfestPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing thin manifest %s", mfestPath)
+	}
+
+	diff := &MergeDiff{ManifestPath: mfestPath}
+
+	for image, digestTags := range rii {
+		for digest, tags := range digestTags {
+			for _, tag := range tags {
+				existingTag, ok := mfest.TagForDigest(digest)
+				if ok && existingTag != tag {
+					return nil, errors.Errorf(
+						"digest %s already present under tag %q, refusing to add it under %q",
+						digest, existingTag, tag,
+					)
+				}
+
+				if mfest.AddImageDigestTag(image, digest, tag) {
+					diff.Added = append(diff.Added, reg.Image{Name: image, Dmap: map[reg.Digest]reg.TagSlice{digest: {tag}}})
+				}
+			}
+		}
+	}
+
+	switch opts.WritePolicy {
+	case WritePolicyStdout:
+		return diff, mfest.WriteTo(os.Stdout)
+	case WritePolicyPullRequestBranch:
+		return diff, mfest.CommitToBranch(opts.BaseDir)
+	default:
+		return diff, mfest.WriteFile(mfestPath)
+	}
+}
+
+func printMergeDiff(diff *MergeDiff) error {
+	printSection("MERGE DIFF: "+diff.ManifestPath, len(diff.Added) == 0)
+
+	for _, img := range diff.Added {
+		for digest, tags := range img.Dmap {
+			for _, tag := range tags {
+				printSection(string(img.Name)+"@"+string(digest)+" -> "+string(tag), true)
+			}
+		}
+	}
+
+	return nil
+}
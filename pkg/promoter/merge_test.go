@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"testing"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+func testStagingInventory() reg.RegInvImage {
+	return reg.RegInvImage{
+		"gcr.io/example-staging/foo": {
+			"sha256:aaaa": reg.TagSlice{"v1", "v2"},
+			"sha256:bbbb": reg.TagSlice{"v3"},
+		},
+		"gcr.io/example-staging/bar": {
+			"sha256:cccc": reg.TagSlice{"v1"},
+		},
+	}
+}
+
+func TestFilterInventoryNoFilters(t *testing.T) {
+	di := &defaultPromoterImplementation{}
+
+	got, err := di.FilterInventory(&Options{}, testStagingInventory())
+	if err != nil {
+		t.Fatalf("FilterInventory() error = %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("FilterInventory() with no filters returned %d images, want 2", len(got))
+	}
+}
+
+func TestFilterInventoryByImage(t *testing.T) {
+	di := &defaultPromoterImplementation{}
+
+	got, err := di.FilterInventory(&Options{FilterImage: "gcr.io/example-staging/foo"}, testStagingInventory())
+	if err != nil {
+		t.Fatalf("FilterInventory() error = %v", err)
+	}
+
+	if _, ok := got["gcr.io/example-staging/bar"]; ok {
+		t.Error("FilterInventory() by image kept an image that should have been filtered out")
+	}
+
+	if _, ok := got["gcr.io/example-staging/foo"]; !ok {
+		t.Error("FilterInventory() by image dropped the image that should have been kept")
+	}
+}
+
+func TestFilterInventoryByTag(t *testing.T) {
+	di := &defaultPromoterImplementation{}
+
+	got, err := di.FilterInventory(&Options{FilterTag: "v1"}, testStagingInventory())
+	if err != nil {
+		t.Fatalf("FilterInventory() error = %v", err)
+	}
+
+	for image, digestTags := range got {
+		for digest, tags := range digestTags {
+			for _, tag := range tags {
+				if tag != "v1" {
+					t.Errorf("FilterInventory() kept tag %q for %s@%s, want only v1", tag, image, digest)
+				}
+			}
+		}
+	}
+
+	if _, ok := got["gcr.io/example-staging/foo"]["sha256:bbbb"]; ok {
+		t.Error("FilterInventory() by tag kept a digest with no matching tag")
+	}
+}
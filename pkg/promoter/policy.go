@@ -0,0 +1,264 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/options"
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/verify"
+	"sigs.k8s.io/yaml"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+// ImagePolicy is modeled on cosign's ClusterImagePolicy v1beta1 CRD. It
+// describes which images a set of authorities must validate before they
+// are allowed to be promoted.
+type ImagePolicy struct {
+	Images      []ImagePolicyImage     `json:"images"`
+	Authorities []ImagePolicyAuthority `json:"authorities"`
+}
+
+// ImagePolicyImage selects the images an ImagePolicy applies to.
+type ImagePolicyImage struct {
+	Glob string `json:"glob"`
+}
+
+// ImagePolicyAuthority describes one signer or set of signers that can
+// validate an image covered by the enclosing ImagePolicy.
+type ImagePolicyAuthority struct {
+	Key     *ImagePolicyKey     `json:"key,omitempty"`
+	Keyless *ImagePolicyKeyless `json:"keyless,omitempty"`
+	CTLog   *ImagePolicyTLog    `json:"ctlog,omitempty"`
+	Rekor   *ImagePolicyTLog    `json:"rekor,omitempty"`
+}
+
+// ImagePolicyKey references a static or KMS-backed public key.
+type ImagePolicyKey struct {
+	Data string `json:"data,omitempty"`
+	KMS  string `json:"kms,omitempty"`
+}
+
+// ImagePolicyKeyless configures keyless (Fulcio) verification.
+type ImagePolicyKeyless struct {
+	Identities []ImagePolicyIdentity `json:"identities"`
+}
+
+// ImagePolicyIdentity matches a Fulcio certificate's issuer/subject pair.
+type ImagePolicyIdentity struct {
+	Issuer  string `json:"issuer"`
+	Subject string `json:"subject"`
+}
+
+// ImagePolicyTLog points to a transparency log used during verification.
+type ImagePolicyTLog struct {
+	URL string `json:"url"`
+}
+
+// PolicyViolation records why an edge did not satisfy any authority in
+// the matching ImagePolicy.
+type PolicyViolation struct {
+	Edge   reg.PromotionEdge
+	Reason string
+}
+
+// EnforceImagePolicy is a mode that evaluates every promotion edge
+// against a ClusterImagePolicy-style document and reports (dry-run) or
+// fails (enforcing) on images that no configured authority can verify.
+func (p *Promoter) EnforceImagePolicy(opts *Options) error {
+	impl := p.implementationFor(opts)
+
+	if err := impl.ValidateOptions(opts); err != nil {
+		return errors.Wrap(err, "validating options")
+	}
+
+	if err := impl.ActivateServiceAccounts(opts); err != nil {
+		return errors.Wrap(err, "activating service accounts")
+	}
+
+	mfests, err := impl.ParseManifests(opts)
+	if err != nil {
+		return errors.Wrap(err, "parsing manifests")
+	}
+
+	sc, err := impl.MakeSyncContext(opts, mfests)
+	if err != nil {
+		return errors.Wrap(err, "creating sync context")
+	}
+
+	promotionEdges, err := impl.GetPromotionEdges(sc, mfests)
+	if err != nil {
+		return errors.Wrap(err, "filtering edges")
+	}
+
+	if err := impl.EnforceImagePolicy(opts, promotionEdges); err != nil {
+		return errors.Wrap(err, "enforcing image policy")
+	}
+
+	// Run the vulnerability scan over the same edge set so policy
+	// violations and scan findings land in the same report.
+	return errors.Wrap(
+		impl.ScanEdges(opts, sc, promotionEdges), "running vulnerability scan",
+	)
+}
+
+// EnforceImagePolicy loads Options.ImagePolicyFile and checks every
+// edge against its authorities. Violations are always printed with
+// printPolicyViolations; when Options.ImagePolicyDryRun is false, a
+// violation also fails the call.
+func (di *defaultPromoterImplementation) EnforceImagePolicy(
+	opts *Options, edges map[reg.PromotionEdge]interface{},
+) error {
+	policy, err := loadImagePolicy(opts.ImagePolicyFile)
+	if err != nil {
+		return errors.Wrap(err, "loading image policy")
+	}
+
+	violations := make([]PolicyViolation, 0)
+	for edge := range edges {
+		image := edge.SrcImage.ImageName.String()
+
+		authorities, err := matchingAuthorities(policy, image)
+		if err != nil {
+			return errors.Wrapf(err, "matching policy for %s", image)
+		}
+
+		if len(authorities) == 0 {
+			violations = append(violations, PolicyViolation{
+				Edge: edge, Reason: "no authority matched image",
+			})
+			continue
+		}
+
+		if !anyAuthorityValidates(authorities, edge) {
+			violations = append(violations, PolicyViolation{
+				Edge: edge, Reason: "no authority validated signature or attestations",
+			})
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if opts.ImagePolicyDryRun {
+		return printPolicyViolations("IMAGE POLICY VIOLATIONS (DRY RUN)", violations)
+	}
+
+	if err := printPolicyViolations("IMAGE POLICY VIOLATIONS", violations); err != nil {
+		return err
+	}
+
+	return errors.Errorf("%d image(s) failed policy validation", len(violations))
+}
+
+func loadImagePolicy(path string) (*ImagePolicy, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", path)
+	}
+
+	policy := &ImagePolicy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling image policy")
+	}
+
+	return policy, nil
+}
+
+// printPolicyViolations writes violations to stdout as YAML under
+// section; no other output format is offered. It is called both in
+// dry-run mode, where
+// violations are the only signal an operator gets, and before
+// EnforceImagePolicy fails the call in enforcing mode, so the offending
+// edges are visible either way.
+func printPolicyViolations(section string, violations []PolicyViolation) error {
+	out, err := yaml.Marshal(violations)
+	if err != nil {
+		return errors.Wrap(err, "marshaling policy violations")
+	}
+
+	printSection(section, false)
+	os.Stdout.Write(out) //nolint:errcheck
+
+	return nil
+}
+
+func matchingAuthorities(policy *ImagePolicy, image string) ([]ImagePolicyAuthority, error) {
+	for _, img := range policy.Images {
+		ok, err := filepath.Match(img.Glob, image)
+		if err != nil {
+			return nil, errors.Wrapf(err, "evaluating glob %q", img.Glob)
+		}
+		if ok {
+			return policy.Authorities, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// anyAuthorityValidates reports whether at least one authority can
+// verify the edge's source signature or attestations, using the same
+// cosign verify plumbing as VerifySourceSignatures. An authority with
+// several keyless.identities entries validates if any one of them
+// does, so each identity gets its own Exec call.
+func anyAuthorityValidates(authorities []ImagePolicyAuthority, edge reg.PromotionEdge) bool {
+	ref := edge.SrcImage.ImageName.String() + "@" + string(edge.Digest)
+	ctx := context.Background()
+
+	for _, authority := range authorities {
+		base := &verify.VerifyCommand{CheckClaims: true}
+
+		if authority.Rekor != nil {
+			base.RekorURL = authority.Rekor.URL
+		} else if authority.CTLog != nil {
+			base.RekorURL = authority.CTLog.URL
+		}
+
+		switch {
+		case authority.Key != nil:
+			verifyCmd := *base
+			verifyCmd.KeyRef = authority.Key.Data
+			if authority.Key.KMS != "" {
+				verifyCmd.KeyRef = authority.Key.KMS
+			}
+
+			if err := verifyCmd.Exec(ctx, []string{ref}); err == nil {
+				return true
+			}
+		case authority.Keyless != nil:
+			for _, id := range authority.Keyless.Identities {
+				verifyCmd := *base
+				verifyCmd.CertVerifyOptions = options.CertVerifyOptions{
+					CertOidcIssuer: id.Issuer,
+					CertIdentity:   id.Subject,
+				}
+
+				if err := verifyCmd.Exec(ctx, []string{ref}); err == nil {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
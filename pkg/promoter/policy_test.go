@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import "testing"
+
+func TestMatchingAuthorities(t *testing.T) {
+	policy := &ImagePolicy{
+		Images: []ImagePolicyImage{
+			{Glob: "gcr.io/example-staging/*"},
+		},
+		Authorities: []ImagePolicyAuthority{
+			{Keyless: &ImagePolicyKeyless{Identities: []ImagePolicyIdentity{
+				{Issuer: "https://accounts.google.com", Subject: "promoter@example.iam.gserviceaccount.com"},
+			}}},
+		},
+	}
+
+	cases := []struct {
+		name      string
+		image     string
+		wantMatch bool
+	}{
+		{"matches configured glob", "gcr.io/example-staging/foo", true},
+		{"does not match other repo", "gcr.io/other-repo/foo", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			authorities, err := matchingAuthorities(policy, tc.image)
+			if err != nil {
+				t.Fatalf("matchingAuthorities() error = %v", err)
+			}
+
+			if got := len(authorities) > 0; got != tc.wantMatch {
+				t.Errorf("matchingAuthorities(%q) matched = %v, want %v", tc.image, got, tc.wantMatch)
+			}
+		})
+	}
+}
+
+func TestMatchingAuthoritiesInvalidGlob(t *testing.T) {
+	policy := &ImagePolicy{
+		Images: []ImagePolicyImage{{Glob: "["}},
+	}
+
+	if _, err := matchingAuthorities(policy, "gcr.io/example-staging/foo"); err == nil {
+		t.Fatal("matchingAuthorities() with an invalid glob: expected an error, got nil")
+	}
+}
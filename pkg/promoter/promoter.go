@@ -41,6 +41,17 @@ func New() *Promoter {
 	}
 }
 
+// implementationFor returns the promoterImplementation selected by
+// Options.Backend, so both the legacy and ggcr registry backends can
+// coexist while callers migrate between them.
+func (p *Promoter) implementationFor(opts *Options) promoterImplementation {
+	if opts.Backend == BackendGGCR {
+		return &ggcrPromoterImplementation{opts: opts}
+	}
+
+	return p.impl
+}
+
 //counterfeiter:generate . promoterImplementation
 
 // promoterImplementation handles all the functionality in the promoter
@@ -56,6 +67,8 @@ type promoterImplementation interface {
 	GetPromotionEdges(*reg.SyncContext, []reg.Manifest) (map[reg.PromotionEdge]interface{}, error)
 	MakeProducerFunction(bool) streamProducerFunc
 	PromoteImages(*reg.SyncContext, map[reg.PromotionEdge]interface{}, streamProducerFunc) error
+	ResolveManifestList(*reg.SyncContext, reg.PromotionEdge) (reg.ImageWithDigestSlice, bool, error)
+	PromoteManifestList(*Options, *reg.SyncContext, reg.PromotionEdge, reg.ImageWithDigestSlice, streamProducerFunc) error
 
 	// Methods for snapshot mode:
 	GetSnapshotSourceRegistry(*Options) (*reg.RegistryContext, error)
@@ -69,6 +82,22 @@ type promoterImplementation interface {
 
 	// Methods for manifest list verification:
 	ValidateManifestLists(opts *Options) error
+
+	// Methods for image policy enforcement:
+	EnforceImagePolicy(*Options, map[reg.PromotionEdge]interface{}) error
+
+	// Methods for image signing and signature verification:
+	VerifySourceSignatures(*Options, *reg.SyncContext, map[reg.PromotionEdge]interface{}) ([]SigningResult, error)
+	SignPromotedImages(*Options, *reg.SyncContext, map[reg.PromotionEdge]interface{}) ([]SigningResult, error)
+
+	// Methods for manifest-merge mode (in-process cip-mm):
+	DumpStagingInventory(*Options) (reg.RegInvImage, error)
+	FilterInventory(*Options, reg.RegInvImage) (reg.RegInvImage, error)
+	MergePromoterManifest(*Options, reg.RegInvImage) (*MergeDiff, error)
+
+	// LoadRegistryConfig parses Options.RegistryConfig so MakeSyncContext
+	// can resolve source images through mirrors and pull-through caches.
+	LoadRegistryConfig(*Options) (*RegistryConfig, error)
 }
 
 // streamProducerFunc is a function that gets the required fields to
@@ -82,99 +111,143 @@ type streamProducerFunc func(
 // PromoteImages is the main method for image promotion
 // it runs by taking all its parameters from a set of options.
 func (p *Promoter) PromoteImages(opts *Options) (err error) {
+	impl := p.implementationFor(opts)
+
 	// Validate the options. Perhaps another image-specific
 	// validation function may be needed.
-	if err := p.impl.ValidateOptions(opts); err != nil {
+	if err := impl.ValidateOptions(opts); err != nil {
 		return errors.Wrap(err, "validating options")
 	}
 
-	if err := p.impl.ActivateServiceAccounts(opts); err != nil {
+	if err := impl.ActivateServiceAccounts(opts); err != nil {
 		return errors.Wrap(err, "activating service accounts")
 	}
 
-	mfests, err := p.impl.ParseManifests(opts)
+	mfests, err := impl.ParseManifests(opts)
 	if err != nil {
 		return errors.Wrap(err, "parsing manifests")
 	}
 
-	sc, err := p.impl.MakeSyncContext(opts, mfests)
+	sc, err := impl.MakeSyncContext(opts, mfests)
 	if err != nil {
 		return errors.Wrap(err, "creating sync context")
 	}
 
-	promotionEdges, err := p.impl.GetPromotionEdges(sc, mfests)
+	promotionEdges, err := impl.GetPromotionEdges(sc, mfests)
 	if err != nil {
 		return errors.Wrap(err, "filtering edges")
 	}
 
+	verifyResults, err := impl.VerifySourceSignatures(opts, sc, promotionEdges)
+	if err != nil {
+		return errors.Wrap(err, "verifying source signatures")
+	}
+
+	if err := printSigningResults("SOURCE SIGNATURE VERIFICATION", verifyResults); err != nil {
+		return errors.Wrap(err, "printing source signature verification results")
+	}
+
 	// MakeProducer
-	producerFunc := p.impl.MakeProducerFunction(sc.UseServiceAccount)
+	producerFunc := impl.MakeProducerFunction(sc.UseServiceAccount)
 
 	// If parseOnly from the original cli.Run fn is kept, this is where it goes
 
+	// Manifest lists get routed to PromoteManifestList per
+	// Options.ManifestListMode; everything else goes through the
+	// regular per-image PromoteImages path.
+	singleImageEdges := make(map[reg.PromotionEdge]interface{})
+	for edge, v := range promotionEdges {
+		index, isList, err := impl.ResolveManifestList(sc, edge)
+		if err != nil {
+			return errors.Wrapf(err, "checking whether %v is a manifest list", edge)
+		}
+
+		if !isList {
+			singleImageEdges[edge] = v
+			continue
+		}
+
+		if err := impl.PromoteManifestList(opts, sc, edge, index, producerFunc); err != nil {
+			return errors.Wrapf(err, "promoting manifest list %v", edge)
+		}
+	}
+
+	if err := impl.PromoteImages(sc, singleImageEdges, producerFunc); err != nil {
+		return errors.Wrap(err, "running promotion")
+	}
+
+	signResults, err := impl.SignPromotedImages(opts, sc, promotionEdges)
+	if err != nil {
+		return errors.Wrap(err, "signing promoted images")
+	}
+
 	return errors.Wrap(
-		p.impl.PromoteImages(sc, promotionEdges, producerFunc),
-		"running promotion",
+		printSigningResults("PROMOTED IMAGE SIGNATURES", signResults),
+		"printing promoted image signing results",
 	)
 }
 
 // Snapshot runs the steps to output a representation in json or yaml of a registry
 func (p *Promoter) Snapshot(opts *Options) (err error) {
-	if err := p.impl.ValidateOptions(opts); err != nil {
+	impl := p.implementationFor(opts)
+
+	if err := impl.ValidateOptions(opts); err != nil {
 		return errors.Wrap(err, "validating options")
 	}
 
-	if err := p.impl.ActivateServiceAccounts(opts); err != nil {
+	if err := impl.ActivateServiceAccounts(opts); err != nil {
 		return errors.Wrap(err, "activating service accounts")
 	}
 
-	mfests, err := p.impl.GetSnapshotManifests(opts)
+	mfests, err := impl.GetSnapshotManifests(opts)
 	if err != nil {
 		return errors.Wrap(err, "getting snapshot manifests")
 	}
 
-	mfests, err = p.impl.AppendManifestToSnapshot(opts, mfests)
+	mfests, err = impl.AppendManifestToSnapshot(opts, mfests)
 	if err != nil {
 		return errors.Wrap(err, "adding the specified manifest to the snapshot context")
 	}
 
-	rii, err := p.impl.GetRegistryImageInventory(opts, mfests)
+	rii, err := impl.GetRegistryImageInventory(opts, mfests)
 	if err != nil {
 		return errors.Wrap(err, "getting registry image inventory")
 	}
 
-	return errors.Wrap(p.impl.Snapshot(opts, rii), "generating snapshot")
+	return errors.Wrap(impl.Snapshot(opts, rii), "generating snapshot")
 }
 
 // SecurityScan runs just like an image promotion, but instead of
 // actually copying the new detected images, it will run a vulnerability
 // scan on them
 func (p *Promoter) SecurityScan(opts *Options) error {
-	if err := p.impl.ValidateOptions(opts); err != nil {
+	impl := p.implementationFor(opts)
+
+	if err := impl.ValidateOptions(opts); err != nil {
 		return errors.Wrap(err, "validating options")
 	}
 
-	if err := p.impl.ActivateServiceAccounts(opts); err != nil {
+	if err := impl.ActivateServiceAccounts(opts); err != nil {
 		return errors.Wrap(err, "activating service accounts")
 	}
 
-	mfests, err := p.impl.ParseManifests(opts)
+	mfests, err := impl.ParseManifests(opts)
 	if err != nil {
 		return errors.Wrap(err, "parsing manifests")
 	}
 
-	sc, err := p.impl.MakeSyncContext(opts, mfests)
+	sc, err := impl.MakeSyncContext(opts, mfests)
 	if err != nil {
 		return errors.Wrap(err, "creating sync context")
 	}
 
-	promotionEdges, err := p.impl.GetPromotionEdges(sc, mfests)
+	promotionEdges, err := impl.GetPromotionEdges(sc, mfests)
 	if err != nil {
 		return errors.Wrap(err, "filtering edges")
 	}
 
 	return errors.Wrap(
-		p.impl.ScanEdges(opts, sc, promotionEdges),
+		impl.ScanEdges(opts, sc, promotionEdges),
 		"running vulnerability scan",
 	)
 }
@@ -182,21 +255,27 @@ func (p *Promoter) SecurityScan(opts *Options) error {
 // CheckManifestLists is a mode that just checks manifests
 // and exists.
 func (p *Promoter) CheckManifestLists(opts *Options) error {
-	if err := p.impl.ValidateOptions(opts); err != nil {
+	impl := p.implementationFor(opts)
+
+	if err := impl.ValidateOptions(opts); err != nil {
 		return errors.Wrap(err, "validating options")
 	}
 
-	if err := p.impl.ActivateServiceAccounts(opts); err != nil {
+	if err := impl.ActivateServiceAccounts(opts); err != nil {
 		return errors.Wrap(err, "activating service accounts")
 	}
 
 	return errors.Wrap(
-		p.impl.ValidateManifestLists(opts), "checking manifest lists",
+		impl.ValidateManifestLists(opts), "checking manifest lists",
 	)
 }
 
 type defaultPromoterImplementation struct{}
 
+// ValidateManifestLists only checks parent-child integrity of manifest
+// lists already present in a snapshot; it does not promote anything.
+// Manifest lists encountered during an actual promotion are instead
+// handled per Options.ManifestListMode, see PromoteManifestList.
 func (di *defaultPromoterImplementation) ValidateManifestLists(opts *Options) error {
 	registry := reg.RegistryName(opts.Repository)
 	images := make([]reg.ImageWithDigestSlice, 0)
@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+// RegistryConfig mirrors the host-keyed schema used by containerd and
+// K3s' registries.yaml, letting the promoter resolve source images
+// through mirrors and pull-through caches instead of contacting the
+// registry named in the manifest directly.
+type RegistryConfig struct {
+	Mirrors map[string]RegistryMirrorConfig `json:"mirrors"`
+}
+
+// RegistryMirrorConfig configures one source host: which endpoints to
+// try, per-host auth/TLS, and path rewrites applied before contacting
+// the host or any of its mirrors.
+type RegistryMirrorConfig struct {
+	Mirrors  RegistryHostMirrors `json:"mirrors"`
+	Configs  RegistryHostConfig  `json:"configs"`
+	Rewrites map[string]string   `json:"rewrites"`
+}
+
+// RegistryHostMirrors lists a host's mirror endpoints, in the order
+// resolveEndpoints places them ahead of the host itself.
+type RegistryHostMirrors struct {
+	Endpoints []string `json:"endpoints"`
+}
+
+// RegistryHostConfig holds per-host auth and TLS settings.
+type RegistryHostConfig struct {
+	Auth RegistryHostAuth `json:"auth"`
+	TLS  RegistryHostTLS  `json:"tls"`
+}
+
+// RegistryHostAuth carries static or token credentials for a host.
+type RegistryHostAuth struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// RegistryHostTLS configures the TLS client used to reach a host.
+type RegistryHostTLS struct {
+	CAFile             string `json:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// LoadRegistryConfig reads and parses Options.RegistryConfig into a
+// RegistryConfig. It is a separate promoterImplementation method, kept
+// apart from MakeSyncContext, so it can be faked in tests.
+func (di *defaultPromoterImplementation) LoadRegistryConfig(opts *Options) (*RegistryConfig, error) {
+	if opts.RegistryConfig == "" {
+		return &RegistryConfig{}, nil
+	}
+
+	path := filepath.Clean(opts.RegistryConfig)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", path)
+	}
+
+	cfg := &RegistryConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling registry config")
+	}
+
+	return cfg, nil
+}
+
+// rewriteSourceImage applies a rewrite rule configured for host whose
+// pattern matches image, e.g. turning `^library/(.*)` + `mirror/library/$1`
+// into a path under the mirror namespace. Rewrites is a map, so when
+// several patterns match, which one is applied is not defined.
+func rewriteSourceImage(cfg *RegistryConfig, host, image string) (string, error) {
+	hostCfg, ok := cfg.Mirrors[host]
+	if !ok {
+		return image, nil
+	}
+
+	for pattern, replacement := range hostCfg.Rewrites {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", errors.Wrapf(err, "compiling rewrite pattern %q for %s", pattern, host)
+		}
+
+		if re.MatchString(image) {
+			return re.ReplaceAllString(image, replacement), nil
+		}
+	}
+
+	return image, nil
+}
+
+// resolveEndpoints returns the ordered list of hosts to try for host:
+// its configured mirror endpoints first, then the host itself.
+func resolveEndpoints(cfg *RegistryConfig, host string) []string {
+	hostCfg, ok := cfg.Mirrors[host]
+	if !ok {
+		return []string{host}
+	}
+
+	return append(append([]string{}, hostCfg.Mirrors.Endpoints...), host)
+}
+
+// MakeSyncContext loads Options.RegistryConfig and, for every source
+// registry in mfests, rewrites its image paths and points it at the
+// first entry of resolveEndpoints (its first mirror endpoint, or the
+// host itself when it has none), carrying over the host's identity
+// token and TLS settings, before handing the rewritten manifests to the
+// regular sync context construction. The remaining endpoints are not
+// tried, and the configured username and password are not applied.
+func (di *defaultPromoterImplementation) MakeSyncContext(
+	opts *Options, mfests []reg.Manifest,
+) (*reg.SyncContext, error) {
+	cfg, err := di.LoadRegistryConfig(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading registry config")
+	}
+
+	resolved, err := resolveSourceRegistries(cfg, mfests)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving source registries through registry config")
+	}
+
+	return reg.MakeSyncContext(resolved, reg.SyncContextOptions{
+		Confirm:           opts.Confirm,
+		UseServiceAccount: opts.UseServiceAccount,
+	})
+}
+
+// resolveSourceRegistries resolves every source RegistryContext against
+// the registry config and rewrites every image path that lives under
+// one of those hosts. Mirror and TLS/auth lookups are always keyed by
+// the host as configured in the manifest (e.g. "docker.io"), never by
+// the mirror endpoint chosen for it, since the mirror endpoint will not
+// itself be a key in Options.RegistryConfig.
+func resolveSourceRegistries(cfg *RegistryConfig, mfests []reg.Manifest) ([]reg.Manifest, error) {
+	resolved := make([]reg.Manifest, len(mfests))
+
+	for i, mfest := range mfests {
+		registries := make([]reg.RegistryContext, len(mfest.Registries))
+		srcHosts := make([]string, 0, len(mfest.Registries))
+
+		for j, rc := range mfest.Registries {
+			if !rc.Src {
+				registries[j] = rc
+				continue
+			}
+
+			host := string(rc.Name)
+			srcHosts = append(srcHosts, host)
+
+			endpoints := resolveEndpoints(cfg, host)
+			rc.Name = reg.RegistryName(endpoints[0])
+
+			if hostCfg, ok := cfg.Mirrors[host]; ok {
+				rc.Token = hostCfg.Configs.Auth.IdentityToken
+				rc.TLS = hostCfg.Configs.TLS
+			}
+
+			registries[j] = rc
+		}
+
+		images := make([]reg.Image, len(mfest.Images))
+		for k, img := range mfest.Images {
+			rewritten, err := rewriteImageForAnyHost(cfg, srcHosts, string(img.Name))
+			if err != nil {
+				return nil, errors.Wrapf(err, "rewriting image %s", img.Name)
+			}
+
+			img.Name = reg.ImageName(rewritten)
+			images[k] = img
+		}
+
+		mfest.Registries = registries
+		mfest.Images = images
+		resolved[i] = mfest
+	}
+
+	return resolved, nil
+}
+
+// rewriteImageForAnyHost applies rewriteSourceImage for each of a
+// manifest's source hosts in turn, returning the first rewrite that
+// actually changes the image path.
+func rewriteImageForAnyHost(cfg *RegistryConfig, hosts []string, image string) (string, error) {
+	for _, host := range hosts {
+		rewritten, err := rewriteSourceImage(cfg, host, image)
+		if err != nil {
+			return "", errors.Wrapf(err, "applying rewrites for host %s", host)
+		}
+
+		if rewritten != image {
+			return rewritten, nil
+		}
+	}
+
+	return image, nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"testing"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+func testRegistryConfig() *RegistryConfig {
+	return &RegistryConfig{
+		Mirrors: map[string]RegistryMirrorConfig{
+			"docker.io": {
+				Mirrors: RegistryHostMirrors{
+					Endpoints: []string{"mirror.example.com", "mirror2.example.com"},
+				},
+				Configs: RegistryHostConfig{
+					Auth: RegistryHostAuth{IdentityToken: "docker-io-token"},
+					TLS:  RegistryHostTLS{CAFile: "/etc/docker-io-ca.pem"},
+				},
+				Rewrites: map[string]string{
+					"^library/(.*)": "mirror/library/$1",
+				},
+			},
+		},
+	}
+}
+
+func TestRewriteSourceImage(t *testing.T) {
+	cfg := testRegistryConfig()
+
+	cases := []struct {
+		name  string
+		host  string
+		image string
+		want  string
+	}{
+		{"rewrites matching pattern", "docker.io", "library/nginx", "mirror/library/nginx"},
+		{"leaves unmatched image alone", "docker.io", "example/nginx", "example/nginx"},
+		{"leaves unconfigured host alone", "gcr.io", "library/nginx", "library/nginx"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := rewriteSourceImage(cfg, tc.host, tc.image)
+			if err != nil {
+				t.Fatalf("rewriteSourceImage() error = %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("rewriteSourceImage(%q, %q) = %q, want %q", tc.host, tc.image, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveEndpoints(t *testing.T) {
+	cfg := testRegistryConfig()
+
+	cases := []struct {
+		name string
+		host string
+		want []string
+	}{
+		{"mirrors before the host itself", "docker.io", []string{"mirror.example.com", "mirror2.example.com", "docker.io"}},
+		{"unconfigured host resolves to itself", "gcr.io", []string{"gcr.io"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := resolveEndpoints(cfg, tc.host)
+			if len(got) != len(tc.want) {
+				t.Fatalf("resolveEndpoints(%q) = %v, want %v", tc.host, got, tc.want)
+			}
+
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("resolveEndpoints(%q)[%d] = %q, want %q", tc.host, i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResolveSourceRegistries(t *testing.T) {
+	cfg := testRegistryConfig()
+
+	mfests := []reg.Manifest{
+		{
+			Registries: []reg.RegistryContext{
+				{Name: "docker.io", Src: true},
+				{Name: "gcr.io/example-prod", Src: false},
+			},
+			Images: []reg.Image{
+				{Name: "library/nginx"},
+			},
+		},
+	}
+
+	resolved, err := resolveSourceRegistries(cfg, mfests)
+	if err != nil {
+		t.Fatalf("resolveSourceRegistries() error = %v", err)
+	}
+
+	src := resolved[0].Registries[0]
+	if src.Name != "mirror.example.com" {
+		t.Errorf("source registry Name = %q, want the first configured mirror endpoint", src.Name)
+	}
+
+	if src.Token != "docker-io-token" {
+		t.Errorf("source registry Token = %q, want the token configured for the original host docker.io", src.Token)
+	}
+
+	if src.TLS.CAFile != "/etc/docker-io-ca.pem" {
+		t.Errorf("source registry TLS.CAFile = %q, want the CA configured for the original host docker.io", src.TLS.CAFile)
+	}
+
+	dst := resolved[0].Registries[1]
+	if dst.Name != "gcr.io/example-prod" {
+		t.Errorf("destination registry Name = %q, want it left untouched", dst.Name)
+	}
+
+	if got, want := string(resolved[0].Images[0].Name), "mirror/library/nginx"; got != want {
+		t.Errorf("image Name = %q, want %q", got, want)
+	}
+}
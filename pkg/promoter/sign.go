@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promoter
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/fulcio"
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/options"
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/sign"
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/verify"
+	"sigs.k8s.io/yaml"
+
+	reg "sigs.k8s.io/promo-tools/v3/legacy/dockerregistry"
+)
+
+// SigningResult records the outcome of verifying or signing a single
+// promotion edge so PromoteImages can print it after the verification
+// and signing phases.
+type SigningResult struct {
+	Edge     reg.PromotionEdge
+	Verified bool
+	Signed   bool
+	Bundle   string
+	Error    string
+}
+
+// VerifySourceSignatures checks every promotion edge whose source lives
+// in a staging registry against the configured cosign policy before
+// PromoteImages is allowed to copy it. When Options.RequireSignedSource
+// is false, edges that fail verification are only recorded in the
+// returned results instead of aborting the run.
+func (di *defaultPromoterImplementation) VerifySourceSignatures(
+	opts *Options, sc *reg.SyncContext, edges map[reg.PromotionEdge]interface{},
+) ([]SigningResult, error) {
+	co := &options.CertVerifyOptions{}
+	verifyCmd := &verify.VerifyCommand{
+		CheckClaims: true,
+		KeyRef:      opts.SigningKeyRef,
+		RekorURL:    opts.RekorURL,
+		CertVerifyOptions: options.CertVerifyOptions{
+			CertOidcIssuer: co.CertOidcIssuer,
+		},
+	}
+
+	ctx := context.Background()
+
+	results := make([]SigningResult, 0, len(edges))
+	for edge := range edges {
+		ref := edge.SrcImage.ImageName.String() + "@" + string(edge.Digest)
+
+		err := verifyCmd.Exec(ctx, []string{ref})
+		res := SigningResult{Edge: edge, Verified: err == nil}
+		if err != nil {
+			res.Error = err.Error()
+			if opts.RequireSignedSource {
+				return results, errors.Wrapf(err, "verifying source signature for %s", ref)
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// SignPromotedImages signs every reference that was just copied to a
+// destination registry, using the destination-side identity (keyless
+// via Fulcio, or a KMS-backed key when Options.SigningKeyRef is set),
+// passing Options.RekorURL to cosign. SignOptions.Upload is always set;
+// no option turns the upload off.
+func (di *defaultPromoterImplementation) SignPromotedImages(
+	opts *Options, sc *reg.SyncContext, edges map[reg.PromotionEdge]interface{},
+) ([]SigningResult, error) {
+	ko := options.KeyOpts{
+		KeyRef:   opts.SigningKeyRef,
+		RekorURL: opts.RekorURL,
+		FulcioURL: func() string {
+			if opts.FulcioURL != "" {
+				return opts.FulcioURL
+			}
+			return fulcio.DefaultFulcioURL
+		}(),
+	}
+
+	ctx := context.Background()
+
+	results := make([]SigningResult, 0, len(edges))
+	for edge := range edges {
+		ref := edge.DstImageTag.ImageName.String() + "@" + string(edge.Digest)
+
+		if err := sign.SignCmd(
+			ctx,
+			ko,
+			options.SignOptions{
+				Upload:      true,
+				Annotations: opts.CosignAnnotations,
+			},
+			[]string{ref},
+		); err != nil {
+			return results, errors.Wrapf(err, "signing promoted image %s", ref)
+		}
+
+		results = append(results, SigningResult{Edge: edge, Signed: true})
+	}
+
+	return results, nil
+}
+
+// printSigningResults writes per-edge signing results to stdout as YAML
+// under section. No other output format is offered.
+func printSigningResults(section string, results []SigningResult) error {
+	out, err := yaml.Marshal(results)
+	if err != nil {
+		return errors.Wrap(err, "marshaling signing results")
+	}
+
+	printSection(section, true)
+	os.Stdout.Write(out) //nolint:errcheck
+
+	return nil
+}